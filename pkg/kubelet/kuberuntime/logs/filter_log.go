@@ -18,98 +18,49 @@ package logs
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"regexp"
+	"time"
 
 	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
 )
 
-// circularLinkedList is an append-only linked list, and behaves like a ring buffer.
-type circularLinkedList struct {
-	capacity, length int
-
-	// head always points to the first node of the list.
-	head *circularLinkedListNode
-	// current points to the last added or modified node.
-	current *circularLinkedListNode
-}
-
-type circularLinkedListNode struct {
-	value indexItem
-	next  *circularLinkedListNode
-}
-
-func newCircularLinkedList(cap int) (*circularLinkedList, error) {
-	if cap < 0 {
-		return nil, fmt.Errorf("invalid capacity: %d", cap)
-	}
-	return &circularLinkedList{
-		capacity: cap,
-	}, nil
-}
-
-func (l *circularLinkedList) Add(val indexItem) {
-	if l.capacity == 0 {
-		return
-	}
-
-	if l.length == 0 {
-		node := &circularLinkedListNode{
-			value: val,
-		}
-		node.next = node
-		l.head = node
-		l.current = node
-		l.length = 1
-		return
-	}
-
-	if l.length == l.capacity {
-		// The linked list is full, so we overwrite the first node.
-		l.current = l.current.next
-		l.current.value = val
-		return
-	}
-
-	// Append a new node, and points the current pointer to the new node.
-	// The `next` pointer of the new node points to the `head` to keep the list circular.
-	l.length++
-	l.current.next = &circularLinkedListNode{
-		value: val,
-		next:  l.head,
-	}
-	l.current = l.current.next
-}
-
-func (l *circularLinkedList) Len() int {
-	return l.length
-}
-
-func (l *circularLinkedList) VisitAll(f func(indexItem) error) error {
-	if l.length == 0 {
-		// the list is empty
-		return nil
-	}
-	for p, i := l.current.next, 0; i < l.length; p, i = p.next, i+1 {
-		if err := f(p.value); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-type indexItem struct {
-	offset int64
-	length int64
-}
-
 type logFilterResult struct {
-	logIndex       *circularLinkedList
+	logIndex       indexStore
 	maxLogLength   int64
 	processedBytes int64
 }
 
+// LogFilterOptions controls which lines filterLog retains in its index. TailLines and
+// WantStream mirror the parameters filterLogByStream has always taken; Since, Until, Matcher
+// and MaxBytes let callers implement kubectl-logs-style `--since`, `--since-time` and grep
+// filtering without a second pass over the file.
+type LogFilterOptions struct {
+	// TailLines is the maximum number of matching lines to retain; 0 retains none, and
+	// a negative value is rejected by newCircularLinkedList.
+	TailLines int64
+	// WantStream selects which CRI stream (stdout/stderr) to retain.
+	WantStream runtimeapi.LogStreamType
+	// Since, if non-zero, excludes lines timestamped strictly before it.
+	Since time.Time
+	// Until, if non-zero, excludes lines timestamped strictly after it.
+	Until time.Time
+	// Matcher, if non-nil, excludes lines whose message payload does not match.
+	Matcher *regexp.Regexp
+	// MaxBytes, if positive, stops processing once that many bytes of the log have been read.
+	MaxBytes int64
+}
+
 func filterLogByStream(f io.ReadSeeker, tailLines int64, wantStream runtimeapi.LogStreamType) (*logFilterResult, error) {
+	return filterLog(f, LogFilterOptions{TailLines: tailLines, WantStream: wantStream})
+}
+
+// filterLog is the general form of filterLogByStream: a single forward pass over f that
+// indexes the offset and length of every line matching opts, without ever materializing a
+// line's contents beyond what's needed to evaluate the predicates.
+func filterLog(f io.ReadSeeker, opts LogFilterOptions) (*logFilterResult, error) {
 	curSize, err := f.Seek(0, io.SeekEnd)
 	if err != nil {
 		return nil, fmt.Errorf("seek to end of log file: %w", err)
@@ -120,13 +71,16 @@ func filterLogByStream(f io.ReadSeeker, tailLines int64, wantStream runtimeapi.L
 	}
 
 	br := bufio.NewReader(f)
-	l, err := newCircularLinkedList(int(tailLines))
+	l, err := newCircularLinkedList(int(opts.TailLines))
 	if err != nil {
 		return nil, err
 	}
 
 	var readBytes, processedBytes, maxLength int64
 	for {
+		if opts.MaxBytes > 0 && processedBytes >= opts.MaxBytes {
+			break
+		}
 		line, err := br.ReadBytes(eol[0])
 		lineLength := int64(len(line))
 		readBytes += lineLength
@@ -142,17 +96,28 @@ func filterLogByStream(f io.ReadSeeker, tailLines int64, wantStream runtimeapi.L
 		if err != nil {
 			return nil, err
 		}
-		if streamType == wantStream {
-			if lineLength > maxLength {
-				maxLength = lineLength
-			}
-			// We save the offset and length of the line rather than the entire line, in case the line is very long,
-			// which might lead to OOM.
-			l.Add(indexItem{
-				offset: processedBytes,
-				length: lineLength,
-			})
+		if streamType != opts.WantStream {
+			processedBytes += int64(len(line))
+			continue
+		}
+		if !matchesTimeRange(line, opts.Since, opts.Until) {
+			processedBytes += int64(len(line))
+			continue
+		}
+		if opts.Matcher != nil && !opts.Matcher.Match(logMessage(line)) {
+			processedBytes += int64(len(line))
+			continue
+		}
+
+		if lineLength > maxLength {
+			maxLength = lineLength
 		}
+		// We save the offset and length of the line rather than the entire line, in case the line is very long,
+		// which might lead to OOM.
+		l.Add(indexItem{
+			offset: processedBytes,
+			length: lineLength,
+		})
 		processedBytes += int64(len(line))
 	}
 
@@ -162,3 +127,42 @@ func filterLogByStream(f io.ReadSeeker, tailLines int64, wantStream runtimeapi.L
 		processedBytes: processedBytes,
 	}, nil
 }
+
+// matchesTimeRange reports whether the CRI timestamp at the front of line falls within
+// [since, until]. A zero since or until disables that bound. Lines whose timestamp can't be
+// parsed are not filtered out on time, so a malformed timestamp never silently drops a line
+// that a regex or stream filter would otherwise keep.
+func matchesTimeRange(line []byte, since, until time.Time) bool {
+	if since.IsZero() && until.IsZero() {
+		return true
+	}
+	ts, _, ok := bytes.Cut(line, []byte{' '})
+	if !ok {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339Nano, string(ts))
+	if err != nil {
+		return true
+	}
+	if !since.IsZero() && t.Before(since) {
+		return false
+	}
+	if !until.IsZero() && t.After(until) {
+		return false
+	}
+	return true
+}
+
+// logMessage strips the leading "<timestamp> <stream> <tag> " prefix from a raw CRI log line,
+// returning just the message payload that a user-supplied regex should match against.
+func logMessage(line []byte) []byte {
+	rest := line
+	for i := 0; i < 3; i++ {
+		_, tail, ok := bytes.Cut(rest, []byte{' '})
+		if !ok {
+			return line
+		}
+		rest = tail
+	}
+	return rest
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+func mustParseRFC3339Nano(t *testing.T, s string) time.Time {
+	t.Helper()
+	ts, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		t.Fatalf("time.Parse(%q): %v", s, err)
+	}
+	return ts
+}
+
+func TestMatchesTimeRange(t *testing.T) {
+	line := []byte("2021-01-01T00:00:01Z stdout F hello\n")
+
+	tests := []struct {
+		name        string
+		since, until string
+		want        bool
+	}{
+		{name: "no bounds", want: true},
+		{name: "equal to since is inclusive", since: "2021-01-01T00:00:01Z", want: true},
+		{name: "equal to until is inclusive", until: "2021-01-01T00:00:01Z", want: true},
+		{name: "before since is excluded", since: "2021-01-01T00:00:02Z", want: false},
+		{name: "after until is excluded", until: "2021-01-01T00:00:00Z", want: false},
+		{name: "within [since, until]", since: "2021-01-01T00:00:00Z", until: "2021-01-01T00:00:02Z", want: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var since, until time.Time
+			if tc.since != "" {
+				since = mustParseRFC3339Nano(t, tc.since)
+			}
+			if tc.until != "" {
+				until = mustParseRFC3339Nano(t, tc.until)
+			}
+			if got := matchesTimeRange(line, since, until); got != tc.want {
+				t.Errorf("matchesTimeRange() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMatchesTimeRangeUnparsableTimestamp(t *testing.T) {
+	since := mustParseRFC3339Nano(t, "2021-01-01T00:00:00Z")
+	line := []byte("not-a-timestamp stdout F hello\n")
+	if !matchesTimeRange(line, since, time.Time{}) {
+		t.Errorf("matchesTimeRange() = false for an unparsable timestamp, want true (not filtered on time)")
+	}
+}
+
+func TestLogMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{name: "well-formed line", line: "2021-01-01T00:00:00Z stdout F hello world\n", want: "hello world\n"},
+		{name: "too few fields returned unchanged", line: "2021-01-01T00:00:00Z stdout\n", want: "2021-01-01T00:00:00Z stdout\n"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := string(logMessage([]byte(tc.line))); got != tc.want {
+				t.Errorf("logMessage() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// criLine builds a single CRI log line in the "<timestamp> <stream> <tag> <message>\n" format
+// that extractStreamFromLog, matchesTimeRange and logMessage all assume.
+func criLine(ts string, stream runtimeapi.LogStreamType, msg string) string {
+	return ts + " " + string(stream) + " F " + msg + "\n"
+}
+
+func TestFilterLogStreamAndTailLines(t *testing.T) {
+	data := []byte(
+		criLine("2021-01-01T00:00:00Z", runtimeapi.Stdout, "out1") +
+			criLine("2021-01-01T00:00:01Z", runtimeapi.Stderr, "err1") +
+			criLine("2021-01-01T00:00:02Z", runtimeapi.Stdout, "out2") +
+			criLine("2021-01-01T00:00:03Z", runtimeapi.Stdout, "out3"),
+	)
+
+	result, err := filterLogByStream(bytes.NewReader(data), 2, runtimeapi.Stdout)
+	if err != nil {
+		t.Fatalf("filterLogByStream: %v", err)
+	}
+	if got, want := result.logIndex.Len(), 2; got != want {
+		t.Fatalf("logIndex.Len() = %d, want %d", got, want)
+	}
+
+	var gotLines []string
+	if err := result.logIndex.VisitAll(func(item indexItem) error {
+		gotLines = append(gotLines, string(data[item.offset:item.offset+item.length]))
+		return nil
+	}); err != nil {
+		t.Fatalf("VisitAll: %v", err)
+	}
+	want := []string{
+		criLine("2021-01-01T00:00:02Z", runtimeapi.Stdout, "out2"),
+		criLine("2021-01-01T00:00:03Z", runtimeapi.Stdout, "out3"),
+	}
+	if !equalStrings(gotLines, want) {
+		t.Errorf("VisitAll lines = %v, want %v", gotLines, want)
+	}
+}
+
+func TestFilterLogTimeRange(t *testing.T) {
+	data := []byte(
+		criLine("2021-01-01T00:00:00Z", runtimeapi.Stdout, "out1") +
+			criLine("2021-01-01T00:00:01Z", runtimeapi.Stdout, "out2") +
+			criLine("2021-01-01T00:00:02Z", runtimeapi.Stdout, "out3"),
+	)
+
+	result, err := filterLog(bytes.NewReader(data), LogFilterOptions{
+		TailLines:  10,
+		WantStream: runtimeapi.Stdout,
+		Since:      mustParseRFC3339Nano(t, "2021-01-01T00:00:01Z"),
+		Until:      mustParseRFC3339Nano(t, "2021-01-01T00:00:01Z"),
+	})
+	if err != nil {
+		t.Fatalf("filterLog: %v", err)
+	}
+	if got, want := result.logIndex.Len(), 1; got != want {
+		t.Fatalf("logIndex.Len() = %d, want %d", got, want)
+	}
+	var gotLine string
+	if err := result.logIndex.VisitAll(func(item indexItem) error {
+		gotLine = string(data[item.offset : item.offset+item.length])
+		return nil
+	}); err != nil {
+		t.Fatalf("VisitAll: %v", err)
+	}
+	want := criLine("2021-01-01T00:00:01Z", runtimeapi.Stdout, "out2")
+	if gotLine != want {
+		t.Errorf("VisitAll line = %q, want %q", gotLine, want)
+	}
+}
+
+func TestFilterLogMatcher(t *testing.T) {
+	data := []byte(
+		criLine("2021-01-01T00:00:00Z", runtimeapi.Stdout, "keep this") +
+			criLine("2021-01-01T00:00:01Z", runtimeapi.Stdout, "drop this"),
+	)
+
+	result, err := filterLog(bytes.NewReader(data), LogFilterOptions{
+		TailLines:  10,
+		WantStream: runtimeapi.Stdout,
+		Matcher:    regexp.MustCompile("^keep"),
+	})
+	if err != nil {
+		t.Fatalf("filterLog: %v", err)
+	}
+	if got, want := result.logIndex.Len(), 1; got != want {
+		t.Fatalf("logIndex.Len() = %d, want %d", got, want)
+	}
+	var gotLine string
+	if err := result.logIndex.VisitAll(func(item indexItem) error {
+		gotLine = string(data[item.offset : item.offset+item.length])
+		return nil
+	}); err != nil {
+		t.Fatalf("VisitAll: %v", err)
+	}
+	want := criLine("2021-01-01T00:00:00Z", runtimeapi.Stdout, "keep this")
+	if gotLine != want {
+		t.Errorf("VisitAll line = %q, want %q", gotLine, want)
+	}
+}
+
+func TestFilterLogMaxBytes(t *testing.T) {
+	line1 := criLine("2021-01-01T00:00:00Z", runtimeapi.Stdout, "out1")
+	line2 := criLine("2021-01-01T00:00:01Z", runtimeapi.Stdout, "out2")
+	data := []byte(line1 + line2)
+
+	result, err := filterLog(bytes.NewReader(data), LogFilterOptions{
+		TailLines:  10,
+		WantStream: runtimeapi.Stdout,
+		MaxBytes:   int64(len(line1)),
+	})
+	if err != nil {
+		t.Fatalf("filterLog: %v", err)
+	}
+	if got, want := result.logIndex.Len(), 1; got != want {
+		t.Fatalf("logIndex.Len() = %d, want %d (MaxBytes should stop before the second line)", got, want)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
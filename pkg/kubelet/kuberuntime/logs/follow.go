@@ -0,0 +1,175 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fsnotify/fsnotify"
+
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// TailAndFollow emits the last tailLines lines of wantStream from the log file at path, then
+// follows appended and rotated content, writing stream-filtered lines to w until ctx is
+// canceled.
+func TailAndFollow(ctx context.Context, path string, tailLines int64, wantStream runtimeapi.LogStreamType, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	defer f.Close()
+
+	result, err := filterLogByStream(f, tailLines, wantStream)
+	if err != nil {
+		return fmt.Errorf("filter log by stream: %w", err)
+	}
+	if err := writeIndexedLines(f, result.logIndex, w); err != nil {
+		return err
+	}
+
+	// filterLogByStream's internal bufio.Reader consumed f all the way to curSize, including
+	// the bytes of any dangling, not-yet-terminated trailing line past processedBytes. Rewind
+	// to processedBytes so the follower's reader replays that partial line from disk instead of
+	// picking up after it and silently truncating the container's first in-progress line.
+	if _, err := f.Seek(result.processedBytes, io.SeekStart); err != nil {
+		return fmt.Errorf("seek to processed offset: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create fsnotify watcher: %w", err)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(path); err != nil {
+		return fmt.Errorf("watch log file: %w", err)
+	}
+
+	follower := &logFollower{
+		path:       path,
+		f:          f,
+		br:         bufio.NewReader(f),
+		wantStream: wantStream,
+		w:          w,
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			switch {
+			case event.Op&fsnotify.Write == fsnotify.Write:
+				if err := follower.drain(); err != nil {
+					return err
+				}
+			case event.Op&(fsnotify.Rename|fsnotify.Remove) != 0:
+				if err := follower.reopen(); err != nil {
+					return err
+				}
+				if err := watcher.Add(path); err != nil {
+					return fmt.Errorf("re-watch log file: %w", err)
+				}
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("watch log file: %w", err)
+		}
+	}
+}
+
+// logFollower tracks the read position within the live CRI log file and re-opens it across
+// rotations, stitching any partial trailing line across the boundary.
+type logFollower struct {
+	path       string
+	f          *os.File
+	br         *bufio.Reader
+	wantStream runtimeapi.LogStreamType
+	w          io.Writer
+
+	// partial holds a trailing line read before it was terminated by eol, so it can be
+	// completed once the rotated file (or its successor) provides the rest.
+	partial []byte
+}
+
+// drain reads and emits any complete, stream-matching lines appended since the last read.
+func (lf *logFollower) drain() error {
+	for {
+		line, err := lf.br.ReadBytes(eol[0])
+		if len(line) > 0 {
+			lf.partial = append(lf.partial, line...)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read line: %w", err)
+		}
+
+		full := lf.partial
+		lf.partial = nil
+
+		streamType, err := extractStreamFromLog(full)
+		if err != nil {
+			return err
+		}
+		if streamType != lf.wantStream {
+			continue
+		}
+		if _, err := lf.w.Write(full); err != nil {
+			return fmt.Errorf("write log line: %w", err)
+		}
+	}
+}
+
+// reopen re-opens the log file at lf.path after a RENAME or REMOVE event. Any unterminated
+// partial line read from the rotated-away segment is kept in lf.partial so drain can stitch it
+// onto the new file's first bytes instead of losing them at the rotation boundary.
+func (lf *logFollower) reopen() error {
+	if err := lf.f.Close(); err != nil {
+		return fmt.Errorf("close rotated log file: %w", err)
+	}
+
+	f, err := os.Open(lf.path)
+	if err != nil {
+		return fmt.Errorf("reopen log file: %w", err)
+	}
+	lf.f = f
+	lf.br = bufio.NewReader(f)
+	return nil
+}
+
+// writeIndexedLines copies the lines recorded by an indexStore from f to w, in index order.
+func writeIndexedLines(f io.ReaderAt, idx indexStore, w io.Writer) error {
+	return idx.VisitAll(func(item indexItem) error {
+		buf := make([]byte, item.length)
+		if _, err := f.ReadAt(buf, item.offset); err != nil {
+			return fmt.Errorf("read indexed line: %w", err)
+		}
+		_, err := w.Write(buf)
+		return err
+	})
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import "fmt"
+
+type indexItem struct {
+	offset int64
+	length int64
+}
+
+// indexStore is a ring buffer of indexItems: once Len() reaches capacity, each further Add
+// overwrites the oldest retained item.
+type indexStore interface {
+	Add(val indexItem)
+	Len() int
+	VisitAll(f func(indexItem) error) error
+}
+
+// largeIndexThreshold is the capacity above which newCircularLinkedList backs the index with
+// arrayRingIndex instead of circularLinkedList, trading per-node linked-list allocation for one
+// preallocated slice.
+const largeIndexThreshold = 10_000
+
+// newCircularLinkedList builds the indexStore for a tail window of the given capacity, picking
+// circularLinkedList for small windows and arrayRingIndex once capacity exceeds
+// largeIndexThreshold.
+func newCircularLinkedList(cap int) (indexStore, error) {
+	if cap < 0 {
+		return nil, fmt.Errorf("invalid capacity: %d", cap)
+	}
+	if cap > largeIndexThreshold {
+		return newArrayRingIndex(cap), nil
+	}
+	return &circularLinkedList{
+		capacity: cap,
+	}, nil
+}
+
+// circularLinkedList is an append-only linked list, and behaves like a ring buffer.
+type circularLinkedList struct {
+	capacity, length int
+
+	// head always points to the first node of the list.
+	head *circularLinkedListNode
+	// current points to the last added or modified node.
+	current *circularLinkedListNode
+}
+
+type circularLinkedListNode struct {
+	value indexItem
+	next  *circularLinkedListNode
+}
+
+func (l *circularLinkedList) Add(val indexItem) {
+	if l.capacity == 0 {
+		return
+	}
+
+	if l.length == 0 {
+		node := &circularLinkedListNode{
+			value: val,
+		}
+		node.next = node
+		l.head = node
+		l.current = node
+		l.length = 1
+		return
+	}
+
+	if l.length == l.capacity {
+		// The linked list is full, so we overwrite the first node.
+		l.current = l.current.next
+		l.current.value = val
+		return
+	}
+
+	// Append a new node, and points the current pointer to the new node.
+	// The `next` pointer of the new node points to the `head` to keep the list circular.
+	l.length++
+	l.current.next = &circularLinkedListNode{
+		value: val,
+		next:  l.head,
+	}
+	l.current = l.current.next
+}
+
+func (l *circularLinkedList) Len() int {
+	return l.length
+}
+
+func (l *circularLinkedList) VisitAll(f func(indexItem) error) error {
+	if l.length == 0 {
+		// the list is empty
+		return nil
+	}
+	for p, i := l.current.next, 0; i < l.length; p, i = p.next, i+1 {
+		if err := f(p.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// arrayRingIndex is an indexStore backed by a single preallocated slice of capacity indexItems.
+// Add overwrites items in place and advances head modulo capacity.
+type arrayRingIndex struct {
+	items    []indexItem
+	capacity int
+	length   int
+	// head is the index the next Add will write to.
+	head int
+}
+
+func newArrayRingIndex(capacity int) *arrayRingIndex {
+	return &arrayRingIndex{
+		items:    make([]indexItem, capacity),
+		capacity: capacity,
+	}
+}
+
+func (r *arrayRingIndex) Add(val indexItem) {
+	if r.capacity == 0 {
+		return
+	}
+	r.items[r.head] = val
+	r.head = (r.head + 1) % r.capacity
+	if r.length < r.capacity {
+		r.length++
+	}
+}
+
+func (r *arrayRingIndex) Len() int {
+	return r.length
+}
+
+func (r *arrayRingIndex) VisitAll(f func(indexItem) error) error {
+	if r.length == 0 {
+		return nil
+	}
+	// Once full, head has wrapped around to point at the oldest retained item; while still
+	// filling, the oldest item is simply the first one written.
+	start := 0
+	if r.length == r.capacity {
+		start = r.head
+	}
+	for i := 0; i < r.length; i++ {
+		if err := f(r.items[(start+i)%r.capacity]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"fmt"
+	"testing"
+)
+
+// visitAllOffsets collects the offsets VisitAll yields, in order.
+func visitAllOffsets(t *testing.T, store indexStore) []int64 {
+	t.Helper()
+	var got []int64
+	if err := store.VisitAll(func(item indexItem) error {
+		got = append(got, item.offset)
+		return nil
+	}); err != nil {
+		t.Fatalf("VisitAll: %v", err)
+	}
+	return got
+}
+
+// TestIndexStoreCapacityZero checks the capacity == 0 no-op is preserved. newCircularLinkedList
+// serves that capacity with circularLinkedList, so arrayRingIndex is not exercised here.
+func TestIndexStoreCapacityZero(t *testing.T) {
+	for _, n := range []int{0} {
+		store, err := newCircularLinkedList(n)
+		if err != nil {
+			t.Fatalf("newCircularLinkedList(%d): %v", n, err)
+		}
+		store.Add(indexItem{offset: 1, length: 1})
+		if got := store.Len(); got != 0 {
+			t.Errorf("Len() = %d, want 0", got)
+		}
+		if got := visitAllOffsets(t, store); len(got) != 0 {
+			t.Errorf("VisitAll yielded %v, want none", got)
+		}
+	}
+}
+
+// TestIndexStorePartialFill checks that Len and VisitAll order are correct before the store
+// has been filled to capacity, for both the linked-list and array-ring backends.
+func TestIndexStorePartialFill(t *testing.T) {
+	for _, capacity := range []int{4, largeIndexThreshold + 4} {
+		t.Run(fmt.Sprintf("capacity=%d", capacity), func(t *testing.T) {
+			store, err := newCircularLinkedList(capacity)
+			if err != nil {
+				t.Fatalf("newCircularLinkedList(%d): %v", capacity, err)
+			}
+			for i := int64(0); i < 3; i++ {
+				store.Add(indexItem{offset: i, length: 1})
+			}
+			if got := store.Len(); got != 3 {
+				t.Errorf("Len() = %d, want 3", got)
+			}
+			want := []int64{0, 1, 2}
+			if got := visitAllOffsets(t, store); !equalOffsets(got, want) {
+				t.Errorf("VisitAll() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// TestIndexStoreWraparound checks that once a store is filled past capacity, VisitAll still
+// yields exactly the last `capacity` items added, oldest to newest.
+func TestIndexStoreWraparound(t *testing.T) {
+	for _, capacity := range []int{4, largeIndexThreshold + 4} {
+		t.Run(fmt.Sprintf("capacity=%d", capacity), func(t *testing.T) {
+			store, err := newCircularLinkedList(capacity)
+			if err != nil {
+				t.Fatalf("newCircularLinkedList(%d): %v", capacity, err)
+			}
+			total := capacity + 3
+			for i := int64(0); i < int64(total); i++ {
+				store.Add(indexItem{offset: i, length: 1})
+			}
+			if got := store.Len(); got != capacity {
+				t.Errorf("Len() = %d, want %d", got, capacity)
+			}
+			want := make([]int64, capacity)
+			for i := range want {
+				want[i] = int64(total-capacity) + int64(i)
+			}
+			if got := visitAllOffsets(t, store); !equalOffsets(got, want) {
+				t.Errorf("VisitAll() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func equalOffsets(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// BenchmarkIndexStoreAdd measures the cost of filling an indexStore to capacity, across both
+// the circularLinkedList and arrayRingIndex backends, at tail windows small enough to stay on
+// the linked list and large enough to cross largeIndexThreshold onto the array-backed ring.
+func BenchmarkIndexStoreAdd(b *testing.B) {
+	for _, n := range []int{1e3, 1e5, 1e6} {
+		b.Run(benchName(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				store, err := newCircularLinkedList(n)
+				if err != nil {
+					b.Fatalf("newCircularLinkedList(%d): %v", n, err)
+				}
+				for j := 0; j < n; j++ {
+					store.Add(indexItem{offset: int64(j), length: 1})
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkIndexStoreVisitAll measures the cost of visiting a full index, across both backends.
+func BenchmarkIndexStoreVisitAll(b *testing.B) {
+	for _, n := range []int{1e3, 1e5, 1e6} {
+		store, err := newCircularLinkedList(n)
+		if err != nil {
+			b.Fatalf("newCircularLinkedList(%d): %v", n, err)
+		}
+		for j := 0; j < n; j++ {
+			store.Add(indexItem{offset: int64(j), length: 1})
+		}
+
+		b.Run(benchName(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := store.VisitAll(func(indexItem) error { return nil }); err != nil {
+					b.Fatalf("VisitAll: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func benchName(n int) string {
+	switch n {
+	case 1e3:
+		return "N=1e3"
+	case 1e5:
+		return "N=1e5"
+	case 1e6:
+		return "N=1e6"
+	default:
+		return "N=unknown"
+	}
+}
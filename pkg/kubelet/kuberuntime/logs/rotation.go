@@ -0,0 +1,181 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// rotatedLogTimeFormat matches the timestamp suffix of a rotated CRI log, e.g.
+// "app_0.log.20220101-120000" or "app_0.log.20220101-120000.gz".
+const rotatedLogTimeFormat = "20060102-150405"
+
+// maxDecompressedSegmentSize caps how much of a single .gz segment we'll decompress to disk.
+const maxDecompressedSegmentSize = 200 * 1024 * 1024 // 200MiB
+
+// logSegment is one CRI log file (live or rotated) plus the stream-filtered index produced by
+// filterLogByStream.
+type logSegment struct {
+	// path is where the filtered bytes can actually be read from: either the original file,
+	// or a decompressed temp file for a .gz segment.
+	path    string
+	cleanup func()
+	result  *logFilterResult
+}
+
+// TailRotatedLogs locates tailLines worth of wantStream lines for the CRI log at basePath,
+// transparently spanning any rotated siblings (plain or gzip-compressed) left alongside it.
+// tailLines must be positive. Segments are returned oldest to newest, so callers can stream
+// them straight through to emission; the indexItem offsets within each segment's
+// logFilterResult are relative to that segment's own path, not basePath.
+func TailRotatedLogs(basePath string, tailLines int64, wantStream runtimeapi.LogStreamType) ([]logSegment, error) {
+	if tailLines <= 0 {
+		return nil, fmt.Errorf("tailLines must be positive, got %d", tailLines)
+	}
+
+	paths, err := rotatedSegmentPaths(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("list rotated log segments: %w", err)
+	}
+
+	var segments []logSegment
+	remaining := tailLines
+	// Walk from the newest segment (the live file) back through older rotations, stopping
+	// once we've accounted for tailLines or run out of history.
+	for i := len(paths) - 1; i >= 0 && remaining > 0; i-- {
+		seg, err := openLogSegment(paths[i], remaining, wantStream)
+		if err != nil {
+			for _, s := range segments {
+				s.cleanup()
+			}
+			return nil, err
+		}
+		segments = append(segments, seg)
+		remaining -= int64(seg.result.logIndex.Len())
+	}
+
+	// segments was built newest-first; reverse it to chronological order.
+	for l, r := 0, len(segments)-1; l < r; l, r = l+1, r-1 {
+		segments[l], segments[r] = segments[r], segments[l]
+	}
+	return segments, nil
+}
+
+// openLogSegment runs filterLogByStream over the segment at path, decompressing it first if
+// it's gzip-compressed.
+func openLogSegment(path string, tailLines int64, wantStream runtimeapi.LogStreamType) (logSegment, error) {
+	readPath := path
+	cleanup := func() {}
+	if strings.HasSuffix(path, ".gz") {
+		tmpPath, err := decompressToTempFile(path, maxDecompressedSegmentSize)
+		if err != nil {
+			return logSegment{}, fmt.Errorf("decompress rotated log %s: %w", path, err)
+		}
+		readPath = tmpPath
+		cleanup = func() { os.Remove(tmpPath) }
+	}
+
+	f, err := os.Open(readPath)
+	if err != nil {
+		cleanup()
+		return logSegment{}, fmt.Errorf("open log segment %s: %w", readPath, err)
+	}
+	defer f.Close()
+
+	result, err := filterLogByStream(f, tailLines, wantStream)
+	if err != nil {
+		cleanup()
+		return logSegment{}, fmt.Errorf("filter log segment %s: %w", path, err)
+	}
+	return logSegment{path: readPath, cleanup: cleanup, result: result}, nil
+}
+
+// decompressToTempFile inflates the gzip file at gzPath into a new temp file capped at
+// maxSize bytes and returns its path. The caller must remove it once done.
+func decompressToTempFile(gzPath string, maxSize int64) (string, error) {
+	gf, err := os.Open(gzPath)
+	if err != nil {
+		return "", fmt.Errorf("open gzip segment: %w", err)
+	}
+	defer gf.Close()
+
+	zr, err := gzip.NewReader(gf)
+	if err != nil {
+		return "", fmt.Errorf("create gzip reader: %w", err)
+	}
+	defer zr.Close()
+
+	tmp, err := os.CreateTemp("", "kuberuntime-log-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("create temp file: %w", err)
+	}
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, io.LimitReader(zr, maxSize+1)); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("inflate gzip segment: %w", err)
+	}
+	if fi, err := tmp.Stat(); err == nil && fi.Size() > maxSize {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("rotated log segment %s exceeds decompressed size cap of %d bytes", gzPath, maxSize)
+	}
+	return tmp.Name(), nil
+}
+
+// rotatedSegmentPaths returns basePath and any rotated siblings next to it, ordered oldest to
+// newest (the live file at basePath sorts last).
+func rotatedSegmentPaths(basePath string) ([]string, error) {
+	matches, err := filepath.Glob(basePath + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	type timestamped struct {
+		path string
+		t    time.Time
+	}
+	rotated := make([]timestamped, 0, len(matches))
+	for _, m := range matches {
+		suffix := strings.TrimPrefix(m, basePath+".")
+		suffix = strings.TrimSuffix(suffix, ".gz")
+		t, err := time.Parse(rotatedLogTimeFormat, suffix)
+		if err != nil {
+			// Not a rotated segment of this log; ignore.
+			continue
+		}
+		rotated = append(rotated, timestamped{path: m, t: t})
+	}
+	sort.Slice(rotated, func(i, j int) bool { return rotated[i].t.Before(rotated[j].t) })
+
+	paths := make([]string, 0, len(rotated)+1)
+	for _, r := range rotated {
+		paths = append(paths, r.path)
+	}
+	if _, err := os.Stat(basePath); err == nil {
+		paths = append(paths, basePath)
+	}
+	return paths, nil
+}
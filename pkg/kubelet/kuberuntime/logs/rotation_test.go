@@ -0,0 +1,196 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile(%s): %v", path, err)
+	}
+}
+
+func writeGzipFile(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create(%s): %v", path, err)
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	if _, err := zw.Write([]byte(content)); err != nil {
+		t.Fatalf("gzip Write: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip Close: %v", err)
+	}
+}
+
+func TestRotatedSegmentPaths(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app_0.log")
+
+	writeFile(t, base, "live")
+	writeFile(t, base+".20210101-000000", "older rotation")
+	writeGzipFile(t, base+".20210102-000000.gz", "newer rotation")
+	writeFile(t, base+".backup", "not a rotated segment")
+
+	got, err := rotatedSegmentPaths(base)
+	if err != nil {
+		t.Fatalf("rotatedSegmentPaths: %v", err)
+	}
+	want := []string{
+		base + ".20210101-000000",
+		base + ".20210102-000000.gz",
+		base,
+	}
+	if !equalStrings(got, want) {
+		t.Errorf("rotatedSegmentPaths() = %v, want %v", got, want)
+	}
+}
+
+func TestTailRotatedLogsRequiresPositiveTailLines(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app_0.log")
+	writeFile(t, base, "")
+
+	for _, tailLines := range []int64{0, -1} {
+		if _, err := TailRotatedLogs(base, tailLines, runtimeapi.Stdout); err == nil {
+			t.Errorf("TailRotatedLogs(tailLines=%d) = nil error, want one", tailLines)
+		}
+	}
+}
+
+func TestTailRotatedLogsSpansPlainAndGzipSegments(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app_0.log")
+
+	oldest := criLine("2021-01-01T00:00:00Z", runtimeapi.Stdout, "oldest")
+	middle := criLine("2021-01-01T00:00:01Z", runtimeapi.Stdout, "middle")
+	newest := criLine("2021-01-01T00:00:02Z", runtimeapi.Stdout, "newest")
+
+	writeGzipFile(t, base+".20210101-000000.gz", oldest)
+	writeFile(t, base+".20210101-000001", middle)
+	writeFile(t, base, newest)
+
+	segments, err := TailRotatedLogs(base, 10, runtimeapi.Stdout)
+	if err != nil {
+		t.Fatalf("TailRotatedLogs: %v", err)
+	}
+	defer func() {
+		for _, seg := range segments {
+			seg.cleanup()
+		}
+	}()
+
+	if got, want := len(segments), 3; got != want {
+		t.Fatalf("len(segments) = %d, want %d", got, want)
+	}
+
+	wantLines := []string{oldest, middle, newest}
+	for i, seg := range segments {
+		if got, want := seg.result.logIndex.Len(), 1; got != want {
+			t.Fatalf("segments[%d].result.logIndex.Len() = %d, want %d", i, got, want)
+		}
+		f, err := os.Open(seg.path)
+		if err != nil {
+			t.Fatalf("open segment %d (%s): %v", i, seg.path, err)
+		}
+		var gotLine string
+		err = seg.result.logIndex.VisitAll(func(item indexItem) error {
+			buf := make([]byte, item.length)
+			if _, err := f.ReadAt(buf, item.offset); err != nil {
+				return err
+			}
+			gotLine = string(buf)
+			return nil
+		})
+		f.Close()
+		if err != nil {
+			t.Fatalf("VisitAll segment %d: %v", i, err)
+		}
+		if gotLine != wantLines[i] {
+			t.Errorf("segments[%d] line = %q, want %q", i, gotLine, wantLines[i])
+		}
+	}
+}
+
+func TestTailRotatedLogsCleansUpOnError(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app_0.log")
+
+	writeFile(t, base, criLine("2021-01-01T00:00:02Z", runtimeapi.Stdout, "newest"))
+	writeGzipFile(t, base+".20210101-000001.gz", criLine("2021-01-01T00:00:01Z", runtimeapi.Stdout, "middle"))
+	// The oldest segment is a corrupt gzip file, so decompressing it fails once the walk
+	// reaches it.
+	writeFile(t, base+".20210101-000000.gz", "not a valid gzip stream")
+
+	before, err := leftoverTempLogFiles(t)
+	if err != nil {
+		t.Fatalf("leftoverTempLogFiles: %v", err)
+	}
+
+	_, err = TailRotatedLogs(base, 10, runtimeapi.Stdout)
+	if err == nil {
+		t.Fatal("TailRotatedLogs() = nil error, want an error from the corrupt gzip segment")
+	}
+
+	after, err := leftoverTempLogFiles(t)
+	if err != nil {
+		t.Fatalf("leftoverTempLogFiles: %v", err)
+	}
+	if len(after) != len(before) {
+		t.Errorf("temp files leaked after error: before=%v after=%v", before, after)
+	}
+}
+
+func TestDecompressToTempFileSizeCap(t *testing.T) {
+	dir := t.TempDir()
+	gzPath := filepath.Join(dir, "big.log.gz")
+	writeGzipFile(t, gzPath, "0123456789")
+
+	_, err := decompressToTempFile(gzPath, 4)
+	if err == nil {
+		t.Fatal("decompressToTempFile() = nil error, want a size-cap error")
+	}
+
+	leftover, globErr := filepath.Glob(filepath.Join(os.TempDir(), "kuberuntime-log-*.tmp"))
+	if globErr != nil {
+		t.Fatalf("Glob: %v", globErr)
+	}
+	// decompressToTempFile copies at most maxSize+1 bytes before rejecting the segment, so a
+	// leaked temp file would hold "01234", not the whole payload.
+	for _, f := range leftover {
+		if data, readErr := os.ReadFile(f); readErr == nil && bytes.Contains(data, []byte("01234")) {
+			t.Errorf("temp file %s from the rejected decompress was not cleaned up", f)
+		}
+	}
+}
+
+func leftoverTempLogFiles(t *testing.T) ([]string, error) {
+	t.Helper()
+	return filepath.Glob(filepath.Join(os.TempDir(), "kuberuntime-log-*.tmp"))
+}
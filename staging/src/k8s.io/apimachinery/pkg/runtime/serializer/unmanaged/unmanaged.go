@@ -6,6 +6,8 @@ import (
 	"sync"
 
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
@@ -13,11 +15,75 @@ import (
 type identifierObject struct {
 	Name    string `json:"name,omitempty"`
 	Encoder string `json:"encoder,omitempty"`
+	Clean   string `json:"clean,omitempty"`
+}
+
+// CleanOptions controls which parts of an object the serializer strips before encoding, beyond
+// the ManagedFields clearing it has always done.
+type CleanOptions struct {
+	// StripResourceVersion clears metadata.resourceVersion.
+	StripResourceVersion bool
+	// StripUID clears metadata.uid.
+	StripUID bool
+	// StripGeneration clears metadata.generation.
+	StripGeneration bool
+	// StripCreationTimestamp resets metadata.creationTimestamp to its zero value.
+	StripCreationTimestamp bool
+	// StripSelfLink clears metadata.selfLink.
+	StripSelfLink bool
+	// StripStatus removes the status subresource entirely. It only applies to
+	// *unstructured.Unstructured objects, since typed API objects have no generic way to clear
+	// an arbitrary Status field.
+	StripStatus bool
+	// AnnotationDenyList lists annotation keys to remove, e.g.
+	// "kubectl.kubernetes.io/last-applied-configuration" or
+	// "deployment.kubernetes.io/revision".
+	AnnotationDenyList []string
+}
+
+// Option mutates a CleanOptions as part of NewSerializerWithOptions's functional-options
+// constructor.
+type Option func(*CleanOptions)
+
+// WithStripResourceVersion toggles clearing metadata.resourceVersion.
+func WithStripResourceVersion(strip bool) Option {
+	return func(o *CleanOptions) { o.StripResourceVersion = strip }
+}
+
+// WithStripUID toggles clearing metadata.uid.
+func WithStripUID(strip bool) Option {
+	return func(o *CleanOptions) { o.StripUID = strip }
+}
+
+// WithStripGeneration toggles clearing metadata.generation.
+func WithStripGeneration(strip bool) Option {
+	return func(o *CleanOptions) { o.StripGeneration = strip }
+}
+
+// WithStripCreationTimestamp toggles resetting metadata.creationTimestamp.
+func WithStripCreationTimestamp(strip bool) Option {
+	return func(o *CleanOptions) { o.StripCreationTimestamp = strip }
+}
+
+// WithStripSelfLink toggles clearing metadata.selfLink.
+func WithStripSelfLink(strip bool) Option {
+	return func(o *CleanOptions) { o.StripSelfLink = strip }
+}
+
+// WithStripStatus toggles removing the status subresource of unstructured objects.
+func WithStripStatus(strip bool) Option {
+	return func(o *CleanOptions) { o.StripStatus = strip }
+}
+
+// WithAnnotationDenyList appends annotation keys to strip from metadata.annotations.
+func WithAnnotationDenyList(keys ...string) Option {
+	return func(o *CleanOptions) { o.AnnotationDenyList = append(o.AnnotationDenyList, keys...) }
 }
 
 type serializer struct {
 	inner      runtime.Serializer
 	identifier runtime.Identifier
+	clean      CleanOptions
 }
 
 func (s *serializer) Encode(obj runtime.Object, w io.Writer) error {
@@ -25,10 +91,43 @@ func (s *serializer) Encode(obj runtime.Object, w io.Writer) error {
 		obj = obj.DeepCopyObject()
 		a, _ := meta.Accessor(obj)
 		a.SetManagedFields(nil)
+		s.applyCleanOptions(obj, a)
 	}
 	return s.inner.Encode(obj, w)
 }
 
+// applyCleanOptions strips whatever s.clean asks for from obj's metadata, and from obj's
+// status if obj is unstructured.
+func (s *serializer) applyCleanOptions(obj runtime.Object, a metav1.Object) {
+	if s.clean.StripResourceVersion {
+		a.SetResourceVersion("")
+	}
+	if s.clean.StripUID {
+		a.SetUID("")
+	}
+	if s.clean.StripGeneration {
+		a.SetGeneration(0)
+	}
+	if s.clean.StripCreationTimestamp {
+		a.SetCreationTimestamp(metav1.Time{})
+	}
+	if s.clean.StripSelfLink {
+		a.SetSelfLink("")
+	}
+	if len(s.clean.AnnotationDenyList) > 0 {
+		annotations := a.GetAnnotations()
+		for _, key := range s.clean.AnnotationDenyList {
+			delete(annotations, key)
+		}
+		a.SetAnnotations(annotations)
+	}
+	if s.clean.StripStatus {
+		if u, ok := obj.(*unstructured.Unstructured); ok {
+			delete(u.Object, "status")
+		}
+	}
+}
+
 func (s *serializer) Identifier() runtime.Identifier {
 	return s.identifier
 }
@@ -39,10 +138,12 @@ func (s *serializer) Decode(data []byte, defaults *schema.GroupVersionKind, into
 
 var identifiersMap sync.Map
 
-func identifier(encoder runtime.Encoder) runtime.Identifier {
+func identifier(encoder runtime.Encoder, clean CleanOptions) runtime.Identifier {
+	cleanFingerprint, _ := json.Marshal(clean)
 	result := identifierObject{
 		Name:    "unmanaged",
 		Encoder: string(encoder.Identifier()),
+		Clean:   string(cleanFingerprint),
 	}
 	if id, ok := identifiersMap.Load(result); ok {
 		return id.(runtime.Identifier)
@@ -58,6 +159,24 @@ func NewSerializer(s runtime.Serializer) runtime.Serializer {
 	}
 	return &serializer{
 		inner:      s,
-		identifier: identifier(s),
+		identifier: identifier(s, CleanOptions{}),
+	}
+}
+
+// NewSerializerWithOptions is like NewSerializer, but additionally strips whatever CleanOptions
+// opts request (on top of the ManagedFields clearing NewSerializer always does) before
+// delegating to inner.
+func NewSerializerWithOptions(inner runtime.Serializer, opts ...Option) runtime.Serializer {
+	if inner == nil {
+		return nil
+	}
+	var clean CleanOptions
+	for _, opt := range opts {
+		opt(&clean)
+	}
+	return &serializer{
+		inner:      inner,
+		identifier: identifier(inner, clean),
+		clean:      clean,
 	}
 }
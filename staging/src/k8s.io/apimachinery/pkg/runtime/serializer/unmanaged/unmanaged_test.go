@@ -0,0 +1,152 @@
+package unmanaged
+
+import (
+	"io"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// recordingEncoder is a minimal runtime.Serializer that just remembers the object it was asked
+// to encode, so tests can inspect what NewSerializer(WithOptions) passed through.
+type recordingEncoder struct {
+	id  string
+	got runtime.Object
+}
+
+func (e *recordingEncoder) Encode(obj runtime.Object, w io.Writer) error {
+	e.got = obj
+	return nil
+}
+
+func (e *recordingEncoder) Identifier() runtime.Identifier {
+	return runtime.Identifier(e.id)
+}
+
+func (e *recordingEncoder) Decode(data []byte, defaults *schema.GroupVersionKind, into runtime.Object) (runtime.Object, *schema.GroupVersionKind, error) {
+	return into, defaults, nil
+}
+
+func newTestObject() *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	u.SetResourceVersion("123")
+	u.SetUID(types.UID("abc"))
+	u.SetGeneration(5)
+	u.SetCreationTimestamp(metav1.Now())
+	u.SetSelfLink("/api/v1/namespaces/default/pods/foo")
+	u.SetAnnotations(map[string]string{
+		"keep": "v",
+		"kubectl.kubernetes.io/last-applied-configuration": "x",
+	})
+	u.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "kubectl"}})
+	u.Object["status"] = map[string]interface{}{"phase": "Running"}
+	return u
+}
+
+func TestEncodeStripsToggledFields(t *testing.T) {
+	inner := &recordingEncoder{}
+	s := NewSerializerWithOptions(inner,
+		WithStripResourceVersion(true),
+		WithStripUID(true),
+		WithStripGeneration(true),
+		WithStripCreationTimestamp(true),
+		WithStripSelfLink(true),
+		WithStripStatus(true),
+		WithAnnotationDenyList("kubectl.kubernetes.io/last-applied-configuration"),
+	)
+
+	if err := s.Encode(newTestObject(), io.Discard); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	a, err := meta.Accessor(inner.got)
+	if err != nil {
+		t.Fatalf("meta.Accessor: %v", err)
+	}
+	if rv := a.GetResourceVersion(); rv != "" {
+		t.Errorf("ResourceVersion = %q, want empty", rv)
+	}
+	if uid := a.GetUID(); uid != "" {
+		t.Errorf("UID = %q, want empty", uid)
+	}
+	if gen := a.GetGeneration(); gen != 0 {
+		t.Errorf("Generation = %d, want 0", gen)
+	}
+	if ts := a.GetCreationTimestamp(); !ts.IsZero() {
+		t.Errorf("CreationTimestamp = %v, want zero", ts)
+	}
+	if sl := a.GetSelfLink(); sl != "" {
+		t.Errorf("SelfLink = %q, want empty", sl)
+	}
+	if a.GetManagedFields() != nil {
+		t.Errorf("ManagedFields = %v, want nil", a.GetManagedFields())
+	}
+	annotations := a.GetAnnotations()
+	if _, ok := annotations["kubectl.kubernetes.io/last-applied-configuration"]; ok {
+		t.Errorf("annotation deny-listed key still present: %v", annotations)
+	}
+	if annotations["keep"] != "v" {
+		t.Errorf("annotations = %v, want keep=v preserved", annotations)
+	}
+	u, ok := inner.got.(*unstructured.Unstructured)
+	if !ok {
+		t.Fatalf("got object of type %T, want *unstructured.Unstructured", inner.got)
+	}
+	if _, ok := u.Object["status"]; ok {
+		t.Errorf("status = %v, want stripped", u.Object["status"])
+	}
+}
+
+func TestEncodeLeavesUnsetFieldsUntouched(t *testing.T) {
+	inner := &recordingEncoder{}
+	s := NewSerializer(inner)
+
+	if err := s.Encode(newTestObject(), io.Discard); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	a, err := meta.Accessor(inner.got)
+	if err != nil {
+		t.Fatalf("meta.Accessor: %v", err)
+	}
+	if rv := a.GetResourceVersion(); rv != "123" {
+		t.Errorf("ResourceVersion = %q, want 123", rv)
+	}
+	if uid := a.GetUID(); uid != "abc" {
+		t.Errorf("UID = %q, want abc", uid)
+	}
+	if gen := a.GetGeneration(); gen != 5 {
+		t.Errorf("Generation = %d, want 5", gen)
+	}
+	if ts := a.GetCreationTimestamp(); ts.IsZero() {
+		t.Errorf("CreationTimestamp = %v, want preserved", ts)
+	}
+	if a.GetManagedFields() != nil {
+		t.Errorf("ManagedFields = %v, want nil (always cleared)", a.GetManagedFields())
+	}
+	annotations := a.GetAnnotations()
+	if annotations["kubectl.kubernetes.io/last-applied-configuration"] != "x" {
+		t.Errorf("annotations = %v, want last-applied-configuration preserved", annotations)
+	}
+	u, ok := inner.got.(*unstructured.Unstructured)
+	if !ok {
+		t.Fatalf("got object of type %T, want *unstructured.Unstructured", inner.got)
+	}
+	if _, ok := u.Object["status"]; !ok {
+		t.Errorf("status stripped, want preserved")
+	}
+}
+
+func TestIdentifierDiffersByCleanOptions(t *testing.T) {
+	stripped := NewSerializerWithOptions(&recordingEncoder{id: "enc"}, WithStripUID(true)).Identifier()
+	unstripped := NewSerializerWithOptions(&recordingEncoder{id: "enc"}, WithStripUID(false)).Identifier()
+
+	if stripped == unstripped {
+		t.Errorf("Identifier() was the same for different CleanOptions: %q", stripped)
+	}
+}